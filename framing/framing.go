@@ -0,0 +1,320 @@
+// hid - Gopher Interface Devices (USB HID)
+// Copyright (c) 2017 Péter Szilágyi. All rights reserved.
+//
+// This file is released under the 3-clause BSD license.
+
+// Package framing implements the two 64-byte HID report framings hardware
+// wallet hubs reimplement over and over: Ledger's APDU wrapping and the
+// U2FHID protocol. Both chunk an APDU exchange across HID output reports and
+// reassemble the response from input reports, so callers only see
+// request/response pairs.
+package framing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/antongulenko/hid"
+)
+
+// reportSize is the HID report payload size every known Ledger and U2FHID
+// device uses; both framings chunk into exactly this many bytes per report.
+const reportSize = 64
+
+// APDUTransport exchanges a single ISO7816-like APDU with a device and
+// returns its response data plus status word, hiding the HID chunking and
+// reassembly needed to get there.
+type APDUTransport interface {
+	Exchange(ctx context.Context, cla, ins, p1, p2 byte, data []byte) (resp []byte, sw uint16, err error)
+}
+
+// reportIO is the *hid.Device surface the framings chunk/reassemble over.
+// It exists so tests can exercise the framing logic against fixed byte
+// fixtures without a real HID device.
+type reportIO interface {
+	ReadContext(ctx context.Context, b []byte) (int, error)
+	WriteContext(ctx context.Context, b []byte) (int, error)
+}
+
+// writeReport writes b as one output report. DoWrite already prepends the
+// HID report-ID prefix byte Windows requires (and every other OS silently
+// tolerates), so b is passed through unmodified here.
+func writeReport(ctx context.Context, dev reportIO, b [reportSize]byte) error {
+	_, err := dev.WriteContext(ctx, b[:])
+	return err
+}
+
+func readReport(ctx context.Context, dev reportIO) ([reportSize]byte, error) {
+	var report [reportSize]byte
+	_, err := dev.ReadContext(ctx, report[:])
+	return report, err
+}
+
+// --- Ledger framing -------------------------------------------------------
+
+// ledgerTag is the fixed tag byte Ledger devices expect in every HID frame,
+// right after the 2-byte logical channel id and regardless of its value.
+const ledgerTag = 0x05
+
+// LedgerFraming implements APDUTransport using the Ledger APDU-over-HID
+// wrapping: a first frame carrying channel id, tag, sequence 0 and a 2-byte
+// total length, followed by continuation frames carrying only channel id,
+// tag and an incrementing sequence number.
+func LedgerFraming(dev *hid.Device, channel uint16) APDUTransport {
+	return &ledgerTransport{dev: dev, channel: channel}
+}
+
+type ledgerTransport struct {
+	dev     reportIO
+	channel uint16
+}
+
+func (t *ledgerTransport) Exchange(ctx context.Context, cla, ins, p1, p2 byte, data []byte) ([]byte, uint16, error) {
+	apdu := append([]byte{cla, ins, p1, p2, byte(len(data))}, data...)
+	if err := t.write(ctx, apdu); err != nil {
+		return nil, 0, err
+	}
+	resp, err := t.read(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(resp) < 2 {
+		return nil, 0, errors.New("framing: ledger response shorter than status word")
+	}
+	sw := binary.BigEndian.Uint16(resp[len(resp)-2:])
+	return resp[:len(resp)-2], sw, nil
+}
+
+func (t *ledgerTransport) write(ctx context.Context, apdu []byte) error {
+	var seq uint16
+	header := make([]byte, 0, reportSize)
+	header = append(header, byte(t.channel>>8), byte(t.channel))
+	header = append(header, ledgerTag)
+	header = append(header, byte(seq>>8), byte(seq))
+	header = append(header, byte(len(apdu)>>8), byte(len(apdu)))
+
+	rest := apdu
+	for {
+		var report [reportSize]byte
+		n := copy(report[:], header)
+		n += copy(report[n:], rest)
+		rest = rest[min(len(rest), reportSize-n):]
+		if err := writeReport(ctx, t.dev, report); err != nil {
+			return err
+		}
+		if len(rest) == 0 {
+			return nil
+		}
+		seq++
+		header = append(header[:0], byte(t.channel>>8), byte(t.channel), ledgerTag, byte(seq>>8), byte(seq))
+	}
+}
+
+func (t *ledgerTransport) read(ctx context.Context) ([]byte, error) {
+	var (
+		seq   uint16
+		total int
+		resp  []byte
+	)
+	for {
+		report, err := readReport(ctx, t.dev)
+		if err != nil {
+			return nil, err
+		}
+		if uint16(report[0])<<8|uint16(report[1]) != t.channel || report[2] != ledgerTag {
+			continue // not our channel/tag, ignore (shared bus with other apps)
+		}
+		gotSeq := uint16(report[3])<<8 | uint16(report[4])
+		if gotSeq != seq {
+			return nil, fmt.Errorf("framing: ledger sequence mismatch, want %d got %d", seq, gotSeq)
+		}
+
+		payload := report[5:]
+		if seq == 0 {
+			total = int(report[5])<<8 | int(report[6])
+			payload = report[7:]
+		}
+		resp = append(resp, payload...)
+		seq++
+		if len(resp) >= total {
+			return resp[:total], nil
+		}
+	}
+}
+
+// --- U2FHID framing --------------------------------------------------------
+
+// U2FHID command/error constants, per the FIDO U2F HID protocol spec.
+const (
+	u2fhidBroadcastChannel = 0xFFFFFFFF
+	u2fhidCmdInit          = 0x86
+	u2fhidCmdMsg           = 0x83
+	u2fhidCmdError         = 0xBF
+	u2fhidErrChannelBusy   = 0x06
+)
+
+// u2fhidInitRespSize is the fixed size of a CTAPHID INIT response: an 8-byte
+// echoed nonce, 4-byte allocated channel id, protocol version, 3 device
+// version bytes and a capabilities flags byte.
+const u2fhidInitRespSize = 17
+
+// U2FHIDFraming implements APDUTransport using the FIDO U2FHID protocol: an
+// INIT frame with a 4-byte channel id, command byte and 2-byte payload
+// length, followed by CONT frames carrying only channel id and an
+// incrementing sequence number.
+//
+// CMD_MSG exchanges must run on a channel allocated via the CTAPHID INIT
+// handshake rather than the broadcast channel (which is reserved for
+// allocating channels), so the first Exchange call performs that handshake
+// and every call after it reuses the allocated channel.
+func U2FHIDFraming(dev *hid.Device) APDUTransport {
+	return &u2fhidTransport{dev: dev}
+}
+
+type u2fhidTransport struct {
+	dev reportIO
+
+	initOnce sync.Once
+	channel  uint32
+	initErr  error
+}
+
+func (t *u2fhidTransport) Exchange(ctx context.Context, cla, ins, p1, p2 byte, data []byte) ([]byte, uint16, error) {
+	channel, err := t.ensureChannel(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	apdu := append([]byte{cla, ins, p1, p2, byte(len(data))}, data...)
+	if err := t.write(ctx, channel, u2fhidCmdMsg, apdu); err != nil {
+		return nil, 0, err
+	}
+	_, resp, err := t.read(ctx, channel)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(resp) < 2 {
+		return nil, 0, errors.New("framing: u2fhid response shorter than status word")
+	}
+	sw := binary.BigEndian.Uint16(resp[len(resp)-2:])
+	return resp[:len(resp)-2], sw, nil
+}
+
+// ensureChannel runs the CTAPHID INIT handshake once per transport, caching
+// the channel id the authenticator allocates for every later Exchange.
+func (t *u2fhidTransport) ensureChannel(ctx context.Context) (uint32, error) {
+	t.initOnce.Do(func() {
+		t.channel, t.initErr = t.init(ctx)
+	})
+	return t.channel, t.initErr
+}
+
+// init sends a CMD_INIT with a random nonce on the broadcast channel and
+// waits for the matching response, which carries the channel id this
+// transport must use for every subsequent exchange.
+func (t *u2fhidTransport) init(ctx context.Context) (uint32, error) {
+	var nonce [8]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return 0, fmt.Errorf("framing: u2fhid init nonce: %w", err)
+	}
+	if err := t.write(ctx, u2fhidBroadcastChannel, u2fhidCmdInit, nonce[:]); err != nil {
+		return 0, err
+	}
+	for {
+		cmd, resp, err := t.read(ctx, u2fhidBroadcastChannel)
+		if err != nil {
+			return 0, err
+		}
+		// The broadcast channel is shared, so other applications' INIT
+		// exchanges can show up here too; only the response echoing our own
+		// nonce is ours.
+		if cmd != u2fhidCmdInit || len(resp) < u2fhidInitRespSize || !bytes.Equal(resp[:8], nonce[:]) {
+			continue
+		}
+		return binary.BigEndian.Uint32(resp[8:12]), nil
+	}
+}
+
+func (t *u2fhidTransport) write(ctx context.Context, channel uint32, cmd byte, payload []byte) error {
+	header := make([]byte, 0, 7)
+	header = append(header, byte(channel>>24), byte(channel>>16), byte(channel>>8), byte(channel))
+	header = append(header, cmd, byte(len(payload)>>8), byte(len(payload)))
+
+	rest := payload
+	var seq byte
+	for {
+		var report [reportSize]byte
+		n := copy(report[:], header)
+		n += copy(report[n:], rest)
+		rest = rest[min(len(rest), reportSize-n):]
+		if err := writeReport(ctx, t.dev, report); err != nil {
+			return err
+		}
+		if len(rest) == 0 {
+			return nil
+		}
+		header = make([]byte, 0, 5)
+		header = append(header, byte(channel>>24), byte(channel>>16), byte(channel>>8), byte(channel), seq)
+		seq++
+	}
+}
+
+// read reassembles a single U2FHID response addressed to channel, returning
+// the command byte carried by its leading frame alongside the reassembled
+// payload.
+func (t *u2fhidTransport) read(ctx context.Context, channel uint32) (cmd byte, payload []byte, err error) {
+	var (
+		seq   byte
+		total int
+		resp  []byte
+		first byte
+	)
+	for {
+		report, err := readReport(ctx, t.dev)
+		if err != nil {
+			return 0, nil, err
+		}
+		gotChannel := uint32(report[0])<<24 | uint32(report[1])<<16 | uint32(report[2])<<8 | uint32(report[3])
+		if gotChannel != channel {
+			continue
+		}
+		frameCmd := report[4]
+		if frameCmd == u2fhidCmdError {
+			// The error code is the frame's 1-byte payload, after the 2-byte length.
+			if report[7] == u2fhidErrChannelBusy {
+				continue // retry: another application is mid-exchange on this channel
+			}
+			return 0, nil, fmt.Errorf("framing: u2fhid error 0x%02x", report[7])
+		}
+
+		if total == 0 {
+			// Leading frame: cmd doubles as the high bit of a 7-bit-set
+			// command, always >= 0x80, so it can't be mistaken for a CONT
+			// sequence byte.
+			first = frameCmd
+			total = int(report[5])<<8 | int(report[6])
+			resp = append(resp, report[7:]...)
+			seq = 0
+		} else {
+			if frameCmd != seq {
+				return 0, nil, fmt.Errorf("framing: u2fhid sequence mismatch, want %d got %d", seq, frameCmd)
+			}
+			resp = append(resp, report[5:]...)
+			seq++
+		}
+		if len(resp) >= total {
+			return first, resp[:total], nil
+		}
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
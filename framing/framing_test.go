@@ -0,0 +1,209 @@
+// hid - Gopher Interface Devices (USB HID)
+// Copyright (c) 2017 Péter Szilágyi. All rights reserved.
+//
+// This file is released under the 3-clause BSD license.
+
+package framing
+
+import (
+	"context"
+	"testing"
+)
+
+// fakeDevice is a reportIO backed by in-memory report queues, standing in
+// for a real HID device so the chunking/reassembly logic can be tested
+// against fixed byte fixtures.
+type fakeDevice struct {
+	written [][]byte
+	toRead  [][reportSize]byte
+
+	// respond, when set, computes each response from the reports written so
+	// far instead of popping from toRead. It's used where a response has to
+	// echo something from its request (e.g. the INIT handshake's nonce),
+	// which a fixed toRead queue can't express.
+	respond func(written [][]byte) [reportSize]byte
+}
+
+func (f *fakeDevice) WriteContext(ctx context.Context, b []byte) (int, error) {
+	cp := make([]byte, len(b))
+	copy(cp, b)
+	f.written = append(f.written, cp)
+	return len(b), nil
+}
+
+func (f *fakeDevice) ReadContext(ctx context.Context, b []byte) (int, error) {
+	if f.respond != nil {
+		report := f.respond(f.written)
+		return copy(b, report[:]), nil
+	}
+	report := f.toRead[0]
+	f.toRead = f.toRead[1:]
+	return copy(b, report[:]), nil
+}
+
+func TestLedgerTransportExchange(t *testing.T) {
+	dev := &fakeDevice{
+		toRead: [][reportSize]byte{
+			report(0x12, 0x34, ledgerTag, 0x00, 0x00, 0x00, 0x03, 0x01, 0x90, 0x00),
+		},
+	}
+	tr := &ledgerTransport{dev: dev, channel: 0x1234}
+
+	resp, sw, err := tr.Exchange(context.Background(), 0xE0, 0x01, 0x02, 0x03, []byte{0xAA})
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if len(dev.written) != 1 {
+		t.Fatalf("wrote %d reports, want 1", len(dev.written))
+	}
+	wantWrite := report(0x12, 0x34, ledgerTag, 0x00, 0x00, 0x00, 0x06, 0xE0, 0x01, 0x02, 0x03, 0x01, 0xAA)
+	if got := dev.written[0]; !reportEqual(got, wantWrite[:]) {
+		t.Fatalf("write report = % x, want % x", got, wantWrite)
+	}
+	if len(resp) != 1 || resp[0] != 0x01 {
+		t.Fatalf("resp = % x, want [01]", resp)
+	}
+	if sw != 0x9000 {
+		t.Fatalf("sw = %#x, want 0x9000", sw)
+	}
+}
+
+func TestLedgerTransportExchangeMultiFrame(t *testing.T) {
+	// A 70-byte response forces a continuation frame: the first frame carries
+	// reportSize-7 = 57 bytes, the rest (13 bytes) lands in the CONT frame.
+	total := 70
+	payload := make([]byte, total)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+
+	init := report(0x00, 0x01, ledgerTag, 0x00, 0x00, byte(total>>8), byte(total))
+	n := copy(init[7:], payload)
+
+	cont := report(0x00, 0x01, ledgerTag, 0x00, 0x01)
+	copy(cont[5:], payload[n:])
+
+	dev := &fakeDevice{toRead: [][reportSize]byte{init, cont}}
+	tr := &ledgerTransport{dev: dev, channel: 1}
+
+	resp, err := tr.read(context.Background())
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(resp) != total {
+		t.Fatalf("len(resp) = %d, want %d", len(resp), total)
+	}
+	for i, b := range resp {
+		if b != byte(i) {
+			t.Fatalf("resp[%d] = %#x, want %#x", i, b, byte(i))
+		}
+	}
+}
+
+// u2fhidFakeInitResponder returns a fakeDevice.respond function that answers
+// the CTAPHID INIT handshake (allocating channel) and then hands subsequent
+// reads to msgResponse, which is invoked once per request written on the
+// allocated channel.
+func u2fhidFakeInitResponder(channel uint32, msgResponse func(written []byte) [reportSize]byte) func(written [][]byte) [reportSize]byte {
+	return func(written [][]byte) [reportSize]byte {
+		last := written[len(written)-1]
+		if last[4] == u2fhidCmdInit {
+			nonce := last[7:15]
+			resp := report(0xFF, 0xFF, 0xFF, 0xFF, u2fhidCmdInit, 0x00, u2fhidInitRespSize)
+			copy(resp[7:15], nonce)
+			resp[15] = byte(channel >> 24)
+			resp[16] = byte(channel >> 16)
+			resp[17] = byte(channel >> 8)
+			resp[18] = byte(channel)
+			return resp
+		}
+		return msgResponse(last)
+	}
+}
+
+func TestU2FHIDTransportExchange(t *testing.T) {
+	channel := uint32(0x01020304)
+	dev := &fakeDevice{}
+	dev.respond = u2fhidFakeInitResponder(channel, func(written []byte) [reportSize]byte {
+		return report(byte(channel>>24), byte(channel>>16), byte(channel>>8), byte(channel), u2fhidCmdMsg, 0x00, 0x03, 0x07, 0x90, 0x00)
+	})
+	tr := &u2fhidTransport{dev: dev}
+
+	resp, sw, err := tr.Exchange(context.Background(), 0xE0, 0x01, 0x02, 0x03, nil)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if len(dev.written) != 2 {
+		t.Fatalf("wrote %d reports, want 2 (INIT, MSG)", len(dev.written))
+	}
+	wantWrite := report(byte(channel>>24), byte(channel>>16), byte(channel>>8), byte(channel), u2fhidCmdMsg, 0x00, 0x05, 0xE0, 0x01, 0x02, 0x03, 0x00)
+	if got := dev.written[1]; !reportEqual(got, wantWrite[:]) {
+		t.Fatalf("write report = % x, want % x", got, wantWrite)
+	}
+	if len(resp) != 1 || resp[0] != 0x07 {
+		t.Fatalf("resp = % x, want [07]", resp)
+	}
+	if sw != 0x9000 {
+		t.Fatalf("sw = %#x, want 0x9000", sw)
+	}
+}
+
+func TestU2FHIDTransportInitAllocatesChannelOnce(t *testing.T) {
+	channel := uint32(0xAABBCCDD)
+	dev := &fakeDevice{}
+	dev.respond = u2fhidFakeInitResponder(channel, func(written []byte) [reportSize]byte {
+		return report(byte(channel>>24), byte(channel>>16), byte(channel>>8), byte(channel), u2fhidCmdMsg, 0x00, 0x02, 0x90, 0x00)
+	})
+	tr := &u2fhidTransport{dev: dev}
+
+	if _, _, err := tr.Exchange(context.Background(), 0, 0, 0, 0, nil); err != nil {
+		t.Fatalf("Exchange #1: %v", err)
+	}
+	if _, _, err := tr.Exchange(context.Background(), 0, 0, 0, 0, nil); err != nil {
+		t.Fatalf("Exchange #2: %v", err)
+	}
+	// One INIT handshake plus one MSG write per exchange: 2 exchanges should
+	// produce 3 writes total, not 4 - the second Exchange must reuse the
+	// channel the first one allocated instead of re-running INIT.
+	if len(dev.written) != 3 {
+		t.Fatalf("wrote %d reports across 2 exchanges, want 3 (1 INIT + 2 MSG)", len(dev.written))
+	}
+}
+
+func TestU2FHIDTransportRetriesOnChannelBusy(t *testing.T) {
+	dev := &fakeDevice{
+		toRead: [][reportSize]byte{
+			report(0xFF, 0xFF, 0xFF, 0xFF, u2fhidCmdError, 0x00, 0x01, u2fhidErrChannelBusy),
+			report(0xFF, 0xFF, 0xFF, 0xFF, u2fhidCmdMsg, 0x00, 0x02, 0x90, 0x00),
+		},
+	}
+	tr := &u2fhidTransport{dev: dev}
+
+	_, resp, err := tr.read(context.Background(), u2fhidBroadcastChannel)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(resp) != 2 || resp[0] != 0x90 || resp[1] != 0x00 {
+		t.Fatalf("resp = % x, want [90 00]", resp)
+	}
+}
+
+// report builds a fixed-size HID report from a short prefix, zero-padded to
+// reportSize, for use as a test fixture.
+func report(prefix ...byte) [reportSize]byte {
+	var r [reportSize]byte
+	copy(r[:], prefix)
+	return r
+}
+
+func reportEqual(got, want []byte) bool {
+	if len(got) != reportSize || len(want) != reportSize {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,19 @@
+// hid - Gopher Interface Devices (USB HID)
+// Copyright (c) 2017 Péter Szilágyi. All rights reserved.
+//
+// This file is released under the 3-clause BSD license.
+
+// +build darwin,!ios,cgo
+
+package hid
+
+// interruptIO is a no-op on macOS: hid_read_timeout pumps a CFRunLoop that's
+// private to hidapi's own IOHIDDevice scheduling, not the run loop of
+// whichever goroutine/thread happens to call interruptIO, so there's no run
+// loop reachable from here whose stop would actually unblock it. ReadContext
+// compensates by polling in bounded hid_read_timeout slices instead of
+// relying on this to unblock a pending read (see readPollInterval in
+// hid_context.go); WriteContext has no equivalent fallback, so a wedged
+// write can still run past ctx's deadline here (also documented on
+// WriteContext).
+func (dev *Device) interruptIO() {}
@@ -0,0 +1,18 @@
+// hid - Gopher Interface Devices (USB HID)
+// Copyright (c) 2017 Péter Szilágyi. All rights reserved.
+//
+// This file is released under the 3-clause BSD license.
+
+// +build linux,cgo
+
+package hid
+
+// interruptIO is a no-op on Linux: stock hidapi-hidraw blocks inside its own
+// poll(2) call on the hidraw fd and exposes no way from the outside to add
+// an eventfd or otherwise wake it early short of patching hidapi itself,
+// which this package doesn't do. ReadContext compensates by polling in
+// bounded hid_read_timeout slices instead of relying on this to unblock a
+// pending read (see readPollInterval in hid_context.go); WriteContext has no
+// equivalent fallback, so a wedged write can still run past ctx's deadline
+// here (also documented on WriteContext).
+func (dev *Device) interruptIO() {}
@@ -0,0 +1,26 @@
+// hid - Gopher Interface Devices (USB HID)
+// Copyright (c) 2017 Péter Szilágyi. All rights reserved.
+//
+// This file is released under the 3-clause BSD license.
+
+// +build windows,cgo
+
+package hid
+
+/*
+#include <windows.h>
+#include <hidapi/hidapi_winapi.h>
+*/
+import "C"
+
+// interruptIO unblocks a pending ReadFile/WriteFile issued by hidapi's
+// Windows backend by cancelling all outstanding I/O on the device handle
+// with CancelIoEx, which is safe to call even if nothing is currently
+// pending.
+func (dev *Device) interruptIO() {
+	if dev.device == nil {
+		return
+	}
+	handle := C.HANDLE(C.hid_winapi_get_platform_handle(dev.device))
+	C.CancelIoEx(handle, nil)
+}
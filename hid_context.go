@@ -0,0 +1,114 @@
+// hid - Gopher Interface Devices (USB HID)
+// Copyright (c) 2017 Péter Szilágyi. All rights reserved.
+//
+// This file is released under the 3-clause BSD license.
+
+package hid
+
+import (
+	"context"
+	"time"
+)
+
+// readPollInterval bounds how long a single DoRead slice blocks in
+// ReadContext. hidapi exposes no portable way to interrupt a blocked
+// hid_read/hid_read_timeout call from another goroutine (interruptIO is a
+// real, immediate unblock only on Windows; see hid_cancel_windows.go vs.
+// hid_cancel_linux.go/hid_cancel_darwin.go), so ReadContext instead bounds
+// its own worst-case cancellation latency by never blocking in hidapi for
+// longer than this at a time.
+const readPollInterval = 250 * time.Millisecond
+
+// cancelPending wakes up a blocked DoRead/DoWrite on this device by poking
+// the OS-specific interrupt hook (see hid_cancel_linux.go, hid_cancel_darwin.go
+// and hid_cancel_windows.go), so the underlying blocking syscall returns
+// immediately where that's actually possible.
+func (dev *Device) cancelPending() {
+	dev.interruptIO()
+}
+
+// ReadContext retrieves an input report from the device, honoring ctx: a
+// deadline on ctx is translated into the same bounded hid_read_timeout DoRead
+// already uses, and ctx.Done() firing mid-read aborts the call instead of
+// blocking until the next report or Close.
+//
+// Internally this reads in readPollInterval slices (each backed by
+// hid_read_timeout) rather than a single unbounded DoRead, because
+// interruptIO can only guarantee an immediate OS-level unblock on Windows
+// (see hid_cancel_windows.go); on Linux and macOS it's best-effort, so
+// slicing is what actually bounds how long ctx.Done() can be left waiting.
+func (dev *Device) ReadContext(ctx context.Context, b []byte) (int, error) {
+	type result struct {
+		n   int
+		err error
+	}
+
+	for {
+		timeout := readPollInterval
+		if deadline, ok := ctx.Deadline(); ok {
+			if d := time.Until(deadline); d < timeout {
+				timeout = d
+			}
+		}
+		if timeout <= 0 {
+			return 0, ctx.Err()
+		}
+
+		done := make(chan result, 1)
+		go func() {
+			n, err := dev.DoRead(b, false, timeout)
+			done <- result{n, err}
+		}()
+
+		select {
+		case r := <-done:
+			if r.n == 0 && r.err == nil {
+				// This slice's hid_read_timeout simply elapsed with no report
+				// pending; keep waiting unless ctx says otherwise.
+				select {
+				case <-ctx.Done():
+					return 0, ctx.Err()
+				default:
+					continue
+				}
+			}
+			return r.n, r.err
+		case <-ctx.Done():
+			dev.cancelPending()
+			<-done // wait for the in-flight slice to actually return before reusing b
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// WriteContext sends an output report, aborting via the same cancellation
+// path as ReadContext if ctx is done before the write completes.
+//
+// Unlike ReadContext, this cannot be sliced into bounded polls: hidapi has no
+// timed write call. So a ctx.Done() here only returns promptly on platforms
+// where interruptIO actually unblocks the pending hid_write (Windows, via
+// CancelIoEx); on Linux and macOS it still waits for the in-flight DoWrite to
+// return on its own, same as today. In practice this is rarely an issue since
+// a healthy device's write rarely blocks for long, but a wedged device (e.g.
+// unplugged mid-transfer) can hang WriteContext past ctx's deadline on those
+// platforms.
+func (dev *Device) WriteContext(ctx context.Context, b []byte) (int, error) {
+	type result struct {
+		n   int
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		n, err := dev.DoWrite(b, false)
+		done <- result{n, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.n, r.err
+	case <-ctx.Done():
+		dev.cancelPending()
+		<-done
+		return 0, ctx.Err()
+	}
+}
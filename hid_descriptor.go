@@ -0,0 +1,194 @@
+// hid - Gopher Interface Devices (USB HID)
+// Copyright (c) 2017 Péter Szilágyi. All rights reserved.
+//
+// This file is released under the 3-clause BSD license.
+
+package hid
+
+import (
+	"fmt"
+)
+
+// Usage identifies a single field within a report by its usage page and
+// usage id, e.g. {GenericDesktop, X} for a joystick's X axis.
+type Usage struct {
+	Page uint16
+	ID   uint16
+}
+
+// Field describes one element of an Input, Output or Feature report, as
+// decoded from the device's report descriptor.
+type Field struct {
+	Usage       Usage
+	ReportID    uint8
+	BitOffset   int // Offset of this field within the report, in bits (after the report id byte)
+	BitSize     int // Size of a single element, in bits
+	Count       int // Number of elements; ParseReportDescriptor emits one Field per element, so always 1
+	LogicalMin  int64
+	LogicalMax  int64
+	PhysicalMin int64
+	PhysicalMax int64
+	Signed      bool
+	IsArray     bool // Array usage (index into Usage table) rather than a variable bitfield
+}
+
+// ReportKind distinguishes the three report directions a Collection's fields
+// can belong to.
+type ReportKind int
+
+const (
+	InputReport ReportKind = iota
+	OutputReport
+	FeatureReport
+)
+
+// Collection is a single HID collection (application, logical, physical, ...)
+// as found in the descriptor, holding the fields nested directly under it and
+// any further nested collections.
+type Collection struct {
+	Usage       Usage
+	Fields      map[ReportKind][]Field
+	Collections []Collection
+}
+
+// ReportDescriptor is the fully decoded report descriptor of a device: a tree
+// of collections, each carrying the input/output/feature fields defined
+// inside it, so callers can build or parse reports without hand-packing
+// bytes at fixed offsets.
+type ReportDescriptor struct {
+	Raw         []byte
+	Collections []Collection
+}
+
+// ReportDescriptor retrieves and parses the raw HID report descriptor of the
+// device. How the raw bytes are obtained depends on the platform: the
+// HIDIOCGRDESC ioctl on Linux (hid_descriptor_linux.go),
+// hid_get_report_descriptor on macOS (hid_descriptor_darwin.go), and a
+// reconstruction from HidD_GetPreparsedData on Windows
+// (hid_descriptor_windows.go).
+func (dev *Device) ReportDescriptor() (*ReportDescriptor, error) {
+	raw, err := dev.rawReportDescriptor()
+	if err != nil {
+		return nil, err
+	}
+	return ParseReportDescriptor(raw)
+}
+
+// field lookup index built lazily by Encode/Decode; not part of the public
+// tree shape since callers generally want Collections for display/discovery
+// and a flat by-usage map for encoding.
+func (d *ReportDescriptor) fieldsByUsage(reportID uint8) map[Usage]Field {
+	out := make(map[Usage]Field)
+	var walk func(c Collection)
+	walk = func(c Collection) {
+		for _, fields := range c.Fields {
+			for _, f := range fields {
+				if f.ReportID == reportID {
+					out[f.Usage] = f
+				}
+			}
+		}
+		for _, nested := range c.Collections {
+			walk(nested)
+		}
+	}
+	for _, c := range d.Collections {
+		walk(c)
+	}
+	return out
+}
+
+// Encode packs values (keyed by Usage) into a report for the given report id,
+// using the field layout discovered in the descriptor. Usages with no known
+// field, or values out of range, are reported as errors rather than silently
+// truncated.
+func (d *ReportDescriptor) Encode(reportID uint8, values map[Usage]int64) ([]byte, error) {
+	fields := d.fieldsByUsage(reportID)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("hid: no fields known for report id %d", reportID)
+	}
+
+	size := 0
+	for _, f := range fields {
+		if end := (f.BitOffset + f.BitSize*f.Count + 7) / 8; end > size {
+			size = end
+		}
+	}
+	report := make([]byte, 1+size)
+	report[0] = reportID
+
+	for usage, value := range values {
+		f, ok := fields[usage]
+		if !ok {
+			return nil, fmt.Errorf("hid: unknown usage %+v for report id %d", usage, reportID)
+		}
+		if value < f.LogicalMin || value > f.LogicalMax {
+			return nil, fmt.Errorf("hid: value %d for usage %+v out of range [%d, %d]", value, usage, f.LogicalMin, f.LogicalMax)
+		}
+		putBits(report[1:], f.BitOffset, f.BitSize, uint64(value))
+	}
+	return report, nil
+}
+
+// Decode unpacks a report into a map of Usage to value, using the field
+// layout discovered in the descriptor for the report's leading report id
+// byte.
+func (d *ReportDescriptor) Decode(report []byte) (reportID uint8, values map[Usage]int64, err error) {
+	if len(report) == 0 {
+		return 0, nil, fmt.Errorf("hid: empty report")
+	}
+	reportID = report[0]
+	fields := d.fieldsByUsage(reportID)
+	if len(fields) == 0 {
+		return reportID, nil, fmt.Errorf("hid: no fields known for report id %d", reportID)
+	}
+
+	values = make(map[Usage]int64, len(fields))
+	for usage, f := range fields {
+		raw := getBits(report[1:], f.BitOffset, f.BitSize)
+		if f.Signed {
+			values[usage] = signExtend(raw, f.BitSize)
+		} else {
+			values[usage] = int64(raw)
+		}
+	}
+	return reportID, values, nil
+}
+
+func putBits(b []byte, bitOffset, bitSize int, value uint64) {
+	for i := 0; i < bitSize; i++ {
+		bit := bitOffset + i
+		byteIdx, bitIdx := bit/8, uint(bit%8)
+		if byteIdx >= len(b) {
+			return
+		}
+		if value&(1<<uint(i)) != 0 {
+			b[byteIdx] |= 1 << bitIdx
+		} else {
+			b[byteIdx] &^= 1 << bitIdx
+		}
+	}
+}
+
+func getBits(b []byte, bitOffset, bitSize int) uint64 {
+	var value uint64
+	for i := 0; i < bitSize; i++ {
+		bit := bitOffset + i
+		byteIdx, bitIdx := bit/8, uint(bit%8)
+		if byteIdx >= len(b) {
+			break
+		}
+		if b[byteIdx]&(1<<bitIdx) != 0 {
+			value |= 1 << uint(i)
+		}
+	}
+	return value
+}
+
+func signExtend(value uint64, bitSize int) int64 {
+	if bitSize >= 64 {
+		return int64(value)
+	}
+	mask := uint64(1) << uint(bitSize-1)
+	return int64((value ^ mask) - mask)
+}
@@ -0,0 +1,33 @@
+// hid - Gopher Interface Devices (USB HID)
+// Copyright (c) 2017 Péter Szilágyi. All rights reserved.
+//
+// This file is released under the 3-clause BSD license.
+
+// +build darwin,!ios,cgo
+
+package hid
+
+/*
+#include <hidapi/hidapi.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// rawReportDescriptor asks hidapi_darwin for the raw descriptor bytes via
+// IOHIDDeviceGetProperty(kIOHIDReportDescriptorKey), which hidapi exposes
+// as hid_get_report_descriptor on the versions vendored here.
+func (dev *Device) rawReportDescriptor() ([]byte, error) {
+	if dev.device == nil {
+		return nil, ErrDeviceClosed
+	}
+	buf := make([]byte, 4096)
+	n := C.hid_get_report_descriptor(dev.device, (*C.uchar)(unsafe.Pointer(&buf[0])), C.size_t(len(buf)))
+	if n < 0 {
+		return nil, fmt.Errorf("hid: failed to read report descriptor: %v", dev.getError())
+	}
+	return buf[:n], nil
+}
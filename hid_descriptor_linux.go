@@ -0,0 +1,63 @@
+// hid - Gopher Interface Devices (USB HID)
+// Copyright (c) 2017 Péter Szilágyi. All rights reserved.
+//
+// This file is released under the 3-clause BSD license.
+
+// +build linux,cgo
+
+package hid
+
+/*
+#include <linux/hidraw.h>
+#include <sys/ioctl.h>
+#include <fcntl.h>
+#include <unistd.h>
+#include <stdlib.h>
+
+// open(2) is variadic, which cgo cannot call directly.
+static int hid_open_rdonly(const char *path) {
+	return open(path, O_RDONLY);
+}
+
+static int hid_get_rdesc_size(int fd, int *size) {
+	return ioctl(fd, HIDIOCGRDESCSIZE, size);
+}
+
+static int hid_get_rdesc(int fd, struct hidraw_report_descriptor *desc) {
+	return ioctl(fd, HIDIOCGRDESC, desc);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// rawReportDescriptor fetches the report descriptor straight from the
+// hidraw device node via HIDIOCGRDESC, bypassing hidapi (which does not
+// expose this on older releases).
+func (dev *Device) rawReportDescriptor() ([]byte, error) {
+	cpath := C.CString(dev.Path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	fd, err := C.hid_open_rdonly(cpath)
+	if fd < 0 {
+		return nil, fmt.Errorf("hid: failed to open %s for descriptor read: %v", dev.Path, err)
+	}
+	defer C.close(fd)
+
+	var size C.int
+	if res, err := C.hid_get_rdesc_size(fd, &size); res < 0 {
+		return nil, fmt.Errorf("hid: HIDIOCGRDESCSIZE failed: %v", err)
+	}
+
+	var desc C.struct_hidraw_report_descriptor
+	desc.size = C.__u32(size)
+	if res, err := C.hid_get_rdesc(fd, &desc); res < 0 {
+		return nil, fmt.Errorf("hid: HIDIOCGRDESC failed: %v", err)
+	}
+
+	raw := C.GoBytes(unsafe.Pointer(&desc.value[0]), size)
+	return raw, nil
+}
@@ -0,0 +1,204 @@
+// hid - Gopher Interface Devices (USB HID)
+// Copyright (c) 2017 Péter Szilágyi. All rights reserved.
+//
+// This file is released under the 3-clause BSD license.
+
+package hid
+
+import (
+	"fmt"
+)
+
+// HID report descriptor item tags, as defined by the HID 1.11 spec section 6.2.2.
+const (
+	itemTagUsagePage     = 0x04
+	itemTagLogicalMin    = 0x14
+	itemTagLogicalMax    = 0x24
+	itemTagPhysicalMin   = 0x34
+	itemTagPhysicalMax   = 0x44
+	itemTagReportSize    = 0x74
+	itemTagReportID      = 0x84
+	itemTagReportCount   = 0x94
+	itemTagCollection    = 0xA0
+	itemTagEndCollection = 0xC0
+	itemTagInput         = 0x80
+	itemTagOutput        = 0x90
+	itemTagFeature       = 0xB0
+	itemTagUsage         = 0x08
+	itemTagUsageMin      = 0x18
+	itemTagUsageMax      = 0x28
+)
+
+// parserState tracks the "global" and "local" items accumulated while
+// walking the descriptor, per HID 1.11 section 6.2.2.7/6.2.2.8.
+type parserState struct {
+	usagePage   uint16
+	logicalMin  int64
+	logicalMax  int64
+	physicalMin int64
+	physicalMax int64
+	reportSize  int
+	reportCount int
+	reportID    uint8
+	usages      []uint16
+	bitOffset   map[reportBitStream]int // next free bit offset per (report id, kind)
+}
+
+// reportBitStream identifies one independent byte stream within a report id:
+// a device can reuse the same report id for, say, both an Input and an
+// Output report, and those pack fields starting at bit 0 of their own
+// stream rather than continuing where the other left off.
+type reportBitStream struct {
+	reportID uint8
+	kind     ReportKind
+}
+
+// ParseReportDescriptor decodes a raw HID report descriptor (as returned by
+// hid_get_report_descriptor, HIDIOCGRDESC, IOHIDDeviceGetProperty or
+// HidD_GetPreparsedData) into a tree of Collections.
+func ParseReportDescriptor(raw []byte) (*ReportDescriptor, error) {
+	st := &parserState{bitOffset: make(map[reportBitStream]int)}
+	root := &Collection{Fields: make(map[ReportKind][]Field)}
+	stack := []*Collection{root}
+
+	pos := 0
+	for pos < len(raw) {
+		tag, data, n, err := nextItem(raw[pos:])
+		if err != nil {
+			return nil, err
+		}
+		pos += n
+		value := itemValue(data)
+
+		switch tag {
+		case itemTagUsagePage:
+			st.usagePage = uint16(value)
+		case itemTagLogicalMin:
+			st.logicalMin = signedItemValue(data)
+		case itemTagLogicalMax:
+			st.logicalMax = signedItemValue(data)
+		case itemTagPhysicalMin:
+			st.physicalMin = signedItemValue(data)
+		case itemTagPhysicalMax:
+			st.physicalMax = signedItemValue(data)
+		case itemTagReportSize:
+			st.reportSize = int(value)
+		case itemTagReportCount:
+			st.reportCount = int(value)
+		case itemTagReportID:
+			st.reportID = uint8(value)
+		case itemTagUsage:
+			st.usages = append(st.usages, uint16(value))
+		case itemTagUsageMin, itemTagUsageMax:
+			// Ranges collapse to individual usages for simplicity; callers
+			// needing the full range can still recover it from Raw.
+			st.usages = append(st.usages, uint16(value))
+		case itemTagCollection:
+			c := Collection{
+				Usage:  Usage{Page: st.usagePage, ID: lastUsage(st)},
+				Fields: make(map[ReportKind][]Field),
+			}
+			parent := stack[len(stack)-1]
+			parent.Collections = append(parent.Collections, c)
+			stack = append(stack, &parent.Collections[len(parent.Collections)-1])
+			st.usages = nil
+		case itemTagEndCollection:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case itemTagInput, itemTagOutput, itemTagFeature:
+			kind := InputReport
+			if tag == itemTagOutput {
+				kind = OutputReport
+			} else if tag == itemTagFeature {
+				kind = FeatureReport
+			}
+			isArray := value&0x02 == 0 // bit 1 clear = array, set = variable
+			cur := stack[len(stack)-1]
+			stream := reportBitStream{reportID: st.reportID, kind: kind}
+			for i := 0; i < st.reportCount; i++ {
+				usage := lastUsage(st)
+				if i < len(st.usages) {
+					usage = st.usages[i]
+				}
+				f := Field{
+					Usage:       Usage{Page: st.usagePage, ID: usage},
+					ReportID:    st.reportID,
+					BitOffset:   st.bitOffset[stream],
+					BitSize:     st.reportSize,
+					Count:       1,
+					LogicalMin:  st.logicalMin,
+					LogicalMax:  st.logicalMax,
+					PhysicalMin: st.physicalMin,
+					PhysicalMax: st.physicalMax,
+					Signed:      st.logicalMin < 0,
+					IsArray:     isArray,
+				}
+				cur.Fields[kind] = append(cur.Fields[kind], f)
+				st.bitOffset[stream] += st.reportSize
+			}
+			st.usages = nil
+		}
+	}
+	return &ReportDescriptor{Raw: raw, Collections: root.Collections}, nil
+}
+
+func lastUsage(st *parserState) uint16 {
+	if len(st.usages) == 0 {
+		return 0
+	}
+	return st.usages[len(st.usages)-1]
+}
+
+// nextItem splits off the next short descriptor item, returning its tag, its
+// raw (little-endian) data bytes, and the total number of bytes consumed.
+// Long items (tag byte 0xFE) are skipped whole, as no HID device in practice
+// emits them.
+func nextItem(b []byte) (tag uint8, data []byte, n int, err error) {
+	if len(b) == 0 {
+		return 0, nil, 0, fmt.Errorf("hid: truncated report descriptor")
+	}
+	prefix := b[0]
+	if prefix == 0xFE {
+		if len(b) < 2 {
+			return 0, nil, 0, fmt.Errorf("hid: truncated long item")
+		}
+		size := int(b[1])
+		return 0xFE, nil, 3 + size, nil
+	}
+
+	size := int(prefix & 0x03)
+	if size == 3 {
+		size = 4
+	}
+	tag = prefix &^ 0x03
+	if len(b) < 1+size {
+		return 0, nil, 0, fmt.Errorf("hid: truncated report descriptor item")
+	}
+	return tag, b[1 : 1+size], 1 + size, nil
+}
+
+// itemValue decodes data as an unsigned little-endian item value. Per HID
+// 1.11 6.2.2.4, most items (Report Count, Report Size, Usage, Report ID, ...)
+// are unsigned; only Logical/Physical Min/Max are spec'd as signed and must
+// go through signedItemValue instead.
+func itemValue(data []byte) int64 {
+	var v int64
+	for i, b := range data {
+		v |= int64(b) << uint(8*i)
+	}
+	return v
+}
+
+// signedItemValue decodes data the same way as itemValue, then sign-extends
+// the result from its 1/2/4-byte width, for the Logical/Physical Min/Max
+// items HID 1.11 6.2.2.4 defines as signed.
+func signedItemValue(data []byte) int64 {
+	v := itemValue(data)
+	if len(data) > 0 {
+		bits := len(data) * 8
+		mask := int64(1) << uint(bits-1)
+		v = (v ^ mask) - mask
+	}
+	return v
+}
@@ -0,0 +1,145 @@
+// hid - Gopher Interface Devices (USB HID)
+// Copyright (c) 2017 Péter Szilágyi. All rights reserved.
+//
+// This file is released under the 3-clause BSD license.
+
+package hid
+
+import "testing"
+
+// item1/item2 build short descriptor items with a 1- or 2-byte value, for
+// assembling raw descriptor fixtures by hand.
+func item1(tag, v byte) []byte        { return []byte{tag | 0x01, v} }
+func item2(tag byte, v uint16) []byte { return []byte{tag | 0x02, byte(v), byte(v >> 8)} }
+
+// TestParseReportDescriptorSharedReportID builds a descriptor where a single
+// report id is reused for both an Input and an Output report, which are
+// independent byte streams: the Output field must start back at bit 0
+// instead of continuing where the Input fields left off.
+func TestParseReportDescriptorSharedReportID(t *testing.T) {
+	const reportID = 5
+	var raw []byte
+	raw = append(raw, item1(itemTagUsagePage, 0x01)...)
+	raw = append(raw, item1(itemTagUsage, 0x02)...)
+	raw = append(raw, itemTagCollection|0x01, 0x01) // Application
+
+	raw = append(raw, item1(itemTagUsagePage, 0x03)...)
+	raw = append(raw, item1(itemTagLogicalMin, 0x00)...)
+	raw = append(raw, item1(itemTagLogicalMax, 0xFF)...)
+	raw = append(raw, item1(itemTagReportSize, 8)...)
+	raw = append(raw, item1(itemTagReportCount, 2)...)
+	raw = append(raw, item1(itemTagReportID, reportID)...)
+	raw = append(raw, item1(itemTagUsage, 10)...)
+	raw = append(raw, item1(itemTagUsage, 11)...)
+	raw = append(raw, item1(itemTagInput, 0x02)...) // Data, Var, Abs
+
+	raw = append(raw, item1(itemTagReportCount, 1)...)
+	raw = append(raw, item1(itemTagUsage, 20)...)
+	raw = append(raw, item1(itemTagOutput, 0x02)...) // Data, Var, Abs
+
+	raw = append(raw, itemTagEndCollection)
+
+	desc, err := ParseReportDescriptor(raw)
+	if err != nil {
+		t.Fatalf("ParseReportDescriptor: %v", err)
+	}
+	if len(desc.Collections) != 1 {
+		t.Fatalf("len(Collections) = %d, want 1", len(desc.Collections))
+	}
+
+	inputs := desc.Collections[0].Fields[InputReport]
+	if len(inputs) != 2 {
+		t.Fatalf("len(inputs) = %d, want 2", len(inputs))
+	}
+	if inputs[0].BitOffset != 0 || inputs[1].BitOffset != 8 {
+		t.Fatalf("input offsets = %d, %d, want 0, 8", inputs[0].BitOffset, inputs[1].BitOffset)
+	}
+
+	outputs := desc.Collections[0].Fields[OutputReport]
+	if len(outputs) != 1 {
+		t.Fatalf("len(outputs) = %d, want 1", len(outputs))
+	}
+	if outputs[0].BitOffset != 0 {
+		t.Fatalf("output BitOffset = %d, want 0 (Input and Output are independent streams)", outputs[0].BitOffset)
+	}
+}
+
+// TestParseReportDescriptorLargeUnsignedItem builds a descriptor with a
+// Report Count of 200, packed (per HID 1.11 6.2.2.2) in the same 1-byte item
+// encodeItem in hid_descriptor_windows.go produces for any count/size from 128
+// to 255.
+// Report Count is an unsigned item: a parser that sign-extends it would read
+// 200 (0xC8) back as -56 and silently drop the whole field.
+func TestParseReportDescriptorLargeUnsignedItem(t *testing.T) {
+	const count = 200
+	var raw []byte
+	raw = append(raw, item1(itemTagUsagePage, 0x01)...)
+	raw = append(raw, item1(itemTagUsage, 0x02)...)
+	raw = append(raw, itemTagCollection|0x01, 0x01) // Application
+
+	raw = append(raw, item1(itemTagUsagePage, 0x03)...)
+	raw = append(raw, item1(itemTagLogicalMin, 0x00)...)
+	raw = append(raw, item1(itemTagLogicalMax, 0x01)...)
+	raw = append(raw, item1(itemTagReportSize, 1)...)
+	raw = append(raw, item1(itemTagReportCount, count)...)
+	raw = append(raw, item1(itemTagUsage, 9)...)
+	raw = append(raw, item1(itemTagInput, 0x02)...) // Data, Var, Abs
+	raw = append(raw, itemTagEndCollection)
+
+	desc, err := ParseReportDescriptor(raw)
+	if err != nil {
+		t.Fatalf("ParseReportDescriptor: %v", err)
+	}
+
+	inputs := desc.Collections[0].Fields[InputReport]
+	if len(inputs) != count {
+		t.Fatalf("len(inputs) = %d, want %d", len(inputs), count)
+	}
+	if inputs[count-1].BitOffset != count-1 {
+		t.Fatalf("inputs[%d].BitOffset = %d, want %d", count-1, inputs[count-1].BitOffset, count-1)
+	}
+}
+
+// TestParseReportDescriptorEncodeDecodeRoundTrip exercises Encode/Decode over
+// a small descriptor to guard the bit-packing math in hid_descriptor.go.
+func TestParseReportDescriptorEncodeDecodeRoundTrip(t *testing.T) {
+	var raw []byte
+	raw = append(raw, item1(itemTagUsagePage, 0x01)...)
+	raw = append(raw, item1(itemTagUsage, 0x02)...)
+	raw = append(raw, itemTagCollection|0x01, 0x01) // Application
+
+	raw = append(raw, item1(itemTagUsagePage, 0x03)...)
+	raw = append(raw, item1(itemTagLogicalMin, 0x00)...)
+	raw = append(raw, item2(itemTagLogicalMax, 0x01FF)...)
+	raw = append(raw, item1(itemTagReportSize, 9)...)
+	raw = append(raw, item1(itemTagReportCount, 1)...)
+	raw = append(raw, item1(itemTagReportID, 7)...)
+	raw = append(raw, item1(itemTagUsage, 42)...)
+	raw = append(raw, item1(itemTagInput, 0x02)...) // Data, Var, Abs
+	raw = append(raw, itemTagEndCollection)
+
+	desc, err := ParseReportDescriptor(raw)
+	if err != nil {
+		t.Fatalf("ParseReportDescriptor: %v", err)
+	}
+
+	usage := Usage{Page: 0x03, ID: 42}
+	report, err := desc.Encode(7, map[Usage]int64{usage: 0x0123})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if report[0] != 7 {
+		t.Fatalf("report[0] = %d, want report id 7", report[0])
+	}
+
+	reportID, values, err := desc.Decode(report)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if reportID != 7 {
+		t.Fatalf("reportID = %d, want 7", reportID)
+	}
+	if values[usage] != 0x0123 {
+		t.Fatalf("values[usage] = %#x, want 0x123", values[usage])
+	}
+}
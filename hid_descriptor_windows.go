@@ -0,0 +1,181 @@
+// hid - Gopher Interface Devices (USB HID)
+// Copyright (c) 2017 Péter Szilágyi. All rights reserved.
+//
+// This file is released under the 3-clause BSD license.
+
+// +build windows,cgo
+
+package hid
+
+/*
+#cgo LDFLAGS: -lhid -lsetupapi
+
+#include <windows.h>
+#include <hidsdi.h>
+#include <hidapi/hidapi_winapi.h>
+
+// HIDP_BUTTON_CAPS and HIDP_VALUE_CAPS each carry their usage in an unnamed
+// union of two differently-shaped structs (Range vs NotRange), which cgo
+// cannot address directly from Go. These shims pick the right member in C,
+// where the anonymous union is legal to access by member name.
+static USAGE hid_button_cap_usage(HIDP_BUTTON_CAPS *bc) {
+	return bc->IsRange ? bc->Range.UsageMin : bc->NotRange.Usage;
+}
+static USAGE hid_value_cap_usage(HIDP_VALUE_CAPS *vc) {
+	return vc->IsRange ? vc->Range.UsageMin : vc->NotRange.Usage;
+}
+// hid_button_cap_count returns how many consecutive 1-bit usages this
+// button capability covers: the span of a usage range, or 1 for a single
+// usage. Also reads the same union, so it lives in C for the same reason
+// as the two helpers above.
+static USHORT hid_button_cap_count(HIDP_BUTTON_CAPS *bc) {
+	if (!bc->IsRange) {
+		return 1;
+	}
+	return (USHORT)(bc->Range.UsageMax - bc->Range.UsageMin + 1);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+)
+
+// rawReportDescriptor reconstructs the descriptor bytes from the preparsed
+// data Windows hands back via HidD_GetPreparsedData, since HidD_* does not
+// expose the raw descriptor directly. HidP_GetCaps plus the button/value
+// capability arrays carry the same information the parser in
+// hid_descriptor_parse.go needs, re-encoded as short items.
+func (dev *Device) rawReportDescriptor() ([]byte, error) {
+	if dev.device == nil {
+		return nil, ErrDeviceClosed
+	}
+	handle := C.HANDLE(C.hid_winapi_get_platform_handle(dev.device))
+	var preparsed C.PHIDP_PREPARSED_DATA
+	if C.HidD_GetPreparsedData(handle, &preparsed) == 0 {
+		return nil, fmt.Errorf("hid: HidD_GetPreparsedData failed")
+	}
+	defer C.HidD_FreePreparsedData(preparsed)
+
+	var caps C.HIDP_CAPS
+	if C.HidP_GetCaps(preparsed, &caps) != C.HIDP_STATUS_SUCCESS {
+		return nil, fmt.Errorf("hid: HidP_GetCaps failed")
+	}
+	return encodeCapsAsDescriptor(preparsed, caps)
+}
+
+// capsKind pairs one HIDP_REPORT_TYPE with the short item tag
+// ParseReportDescriptor expects its fields to be collected under, plus the
+// button/value capability counts HidP_GetCaps already reported for it.
+type capsKind struct {
+	reportType C.HIDP_REPORT_TYPE
+	itemTag    byte
+	numButtons C.USHORT
+	numValues  C.USHORT
+}
+
+// encodeCapsAsDescriptor walks the button and value capability arrays
+// HidP_GetButtonCaps/HidP_GetValueCaps return for preparsed data and
+// re-emits each one as a short-item field: Usage Page, Logical Min/Max,
+// Report Size, Report ID, Report Count, Usage and an Input/Output/Feature
+// item, all nested in a single top-level Application collection. The
+// resulting Field tree is not the one hid_descriptor_linux.go and
+// hid_descriptor_darwin.go get from the device's real descriptor bytes:
+// nested collections are flattened into that one collection, Physical
+// Min/Max are not emitted (they parse as 0), and a usage range carries only
+// its UsageMin.
+func encodeCapsAsDescriptor(preparsed C.PHIDP_PREPARSED_DATA, caps C.HIDP_CAPS) ([]byte, error) {
+	raw := encodeItem(itemTagUsagePage, int64(caps.UsagePage))
+	raw = append(raw, encodeItem(itemTagUsage, int64(caps.Usage))...)
+	raw = append(raw, itemTagCollection|0x01, 0x01) // Application collection
+
+	kinds := []capsKind{
+		{C.HidP_Input, itemTagInput, caps.NumberInputButtonCaps, caps.NumberInputValueCaps},
+		{C.HidP_Output, itemTagOutput, caps.NumberOutputButtonCaps, caps.NumberOutputValueCaps},
+		{C.HidP_Feature, itemTagFeature, caps.NumberFeatureButtonCaps, caps.NumberFeatureValueCaps},
+	}
+	fields := 0
+	for _, k := range kinds {
+		n, err := encodeButtonCaps(preparsed, k, &raw)
+		if err != nil {
+			return nil, err
+		}
+		fields += n
+
+		n, err = encodeValueCaps(preparsed, k, &raw)
+		if err != nil {
+			return nil, err
+		}
+		fields += n
+	}
+	raw = append(raw, itemTagEndCollection)
+	if fields == 0 {
+		return nil, fmt.Errorf("hid: device reported no button or value capabilities")
+	}
+	return raw, nil
+}
+
+func encodeButtonCaps(preparsed C.PHIDP_PREPARSED_DATA, k capsKind, raw *[]byte) (int, error) {
+	if k.numButtons == 0 {
+		return 0, nil
+	}
+	caps := make([]C.HIDP_BUTTON_CAPS, k.numButtons)
+	got := k.numButtons
+	if C.HidP_GetButtonCaps(k.reportType, &caps[0], &got, preparsed) != C.HIDP_STATUS_SUCCESS {
+		return 0, fmt.Errorf("hid: HidP_GetButtonCaps failed")
+	}
+	for i := range caps[:got] {
+		bc := &caps[i]
+		usage := int64(C.hid_button_cap_usage(bc))
+		count := int(C.hid_button_cap_count(bc))
+		*raw = append(*raw, encodeItem(itemTagUsagePage, int64(bc.UsagePage))...)
+		*raw = append(*raw, encodeItem(itemTagLogicalMin, 0)...)
+		*raw = append(*raw, encodeItem(itemTagLogicalMax, 1)...)
+		*raw = append(*raw, encodeItem(itemTagReportSize, 1)...)
+		*raw = append(*raw, encodeItem(itemTagReportID, int64(bc.ReportID))...)
+		*raw = append(*raw, encodeItem(itemTagReportCount, int64(count))...)
+		*raw = append(*raw, encodeItem(itemTagUsage, usage)...)
+		*raw = append(*raw, encodeItem(k.itemTag, int64(bc.BitField))...)
+	}
+	return int(got), nil
+}
+
+func encodeValueCaps(preparsed C.PHIDP_PREPARSED_DATA, k capsKind, raw *[]byte) (int, error) {
+	if k.numValues == 0 {
+		return 0, nil
+	}
+	caps := make([]C.HIDP_VALUE_CAPS, k.numValues)
+	got := k.numValues
+	if C.HidP_GetValueCaps(k.reportType, &caps[0], &got, preparsed) != C.HIDP_STATUS_SUCCESS {
+		return 0, fmt.Errorf("hid: HidP_GetValueCaps failed")
+	}
+	for i := range caps[:got] {
+		vc := &caps[i]
+		usage := int64(C.hid_value_cap_usage(vc))
+		*raw = append(*raw, encodeItem(itemTagUsagePage, int64(vc.UsagePage))...)
+		*raw = append(*raw, encodeItem(itemTagLogicalMin, int64(vc.LogicalMin))...)
+		*raw = append(*raw, encodeItem(itemTagLogicalMax, int64(vc.LogicalMax))...)
+		*raw = append(*raw, encodeItem(itemTagReportSize, int64(vc.BitSize))...)
+		*raw = append(*raw, encodeItem(itemTagReportID, int64(vc.ReportID))...)
+		*raw = append(*raw, encodeItem(itemTagReportCount, int64(vc.ReportCount))...)
+		*raw = append(*raw, encodeItem(itemTagUsage, usage)...)
+		*raw = append(*raw, encodeItem(k.itemTag, int64(vc.BitField))...)
+	}
+	return int(got), nil
+}
+
+// encodeItem emits a short descriptor item for tag, sizing the item to the
+// smallest of the 1/2/4-byte encodings that can hold v without truncating
+// it, per HID 1.11 6.2.2.2. Logical/Physical Min/Max are read back signed
+// (see signedItemValue), so their width is chosen as two's complement.
+func encodeItem(tag byte, v int64) []byte {
+	signed := tag == itemTagLogicalMin || tag == itemTagLogicalMax || tag == itemTagPhysicalMin || tag == itemTagPhysicalMax
+	switch {
+	case signed && v >= -0x80 && v <= 0x7F, !signed && v >= 0 && v <= 0xFF:
+		return []byte{tag | 0x01, byte(v)}
+	case signed && v >= -0x8000 && v <= 0x7FFF, !signed && v >= 0 && v <= 0xFFFF:
+		return []byte{tag | 0x02, byte(v), byte(v >> 8)}
+	default:
+		return []byte{tag | 0x03, byte(v), byte(v >> 8), byte(v >> 16), byte(v >> 24)}
+	}
+}
@@ -203,9 +203,6 @@ func (dev *Device) DoRead(b []byte, featureReport bool, timeout time.Duration) (
 	} else {
 		if timeout > 0 {
 			read = int(C.hid_read_timeout(device, (*C.uchar)(&b[0]), C.size_t(len(b)), C.int(timeout/time.Millisecond)))
-			if read == 0 {
-
-			}
 		} else {
 			read = int(C.hid_read(device, (*C.uchar)(&b[0]), C.size_t(len(b))))
 		}
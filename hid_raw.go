@@ -0,0 +1,309 @@
+// hid - Gopher Interface Devices (USB HID)
+// Copyright (c) 2017 Péter Szilágyi. All rights reserved.
+//
+// This file is released under the 3-clause BSD license. Note however that this
+// backend depends on libusb, released under LGNU GPL 2.1 or later.
+
+// +build linux,cgo darwin,!ios,cgo windows,cgo
+
+package hid
+
+/*
+#cgo LDFLAGS: -lusb-1.0
+
+#include <libusb-1.0/libusb.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+// rawInitOnce guards libusb_init, which (like hidapi) must only run once per
+// process and is not safe to race against enumeration or device open/close.
+var (
+	rawInitOnce sync.Once
+	rawInitErr  error
+)
+
+func rawInit() error {
+	rawInitOnce.Do(func() {
+		if res := C.libusb_init(nil); res < 0 {
+			rawInitErr = fmt.Errorf("hid: failed to initialize libusb: %v", res)
+		}
+	})
+	return rawInitErr
+}
+
+// EndpointInfo describes a single endpoint exposed by an interface, as needed
+// to pick the right endpoint address for a bulk or interrupt transfer.
+type EndpointInfo struct {
+	Address       byte // Endpoint address, high bit set for IN endpoints
+	Interrupt     bool // Whether this is an interrupt (as opposed to bulk) endpoint
+	MaxPacketSize int  // Maximum packet size reported by the endpoint descriptor
+}
+
+// RawDeviceInfo is the libusb counterpart of DeviceInfo: it describes a raw USB
+// interface rather than a HID top-level collection, so it additionally carries
+// the bus/address addressing libusb needs to open it. The interface to claim
+// on Open is DeviceInfo.Interface (bInterfaceNumber) - there's deliberately no
+// second Interface field here for libusb's config-descriptor array index,
+// which only coincides with bInterfaceNumber when a device declares its
+// interfaces in order.
+type RawDeviceInfo struct {
+	DeviceInfo // Embed the common infos for easier access
+
+	Transport Transport      // Which enumeration backend produced this entry
+	Bus       int            // USB bus number the device is attached to
+	Address   int            // Device address on that bus
+	Endpoints []EndpointInfo // Endpoints exposed by the interface
+}
+
+// Transport identifies which enumeration backend produced a RawDeviceInfo.
+type Transport int
+
+const (
+	// TransportRawUSB means this entry came from EnumerateRaw's libusb walk:
+	// Bus, Address, Interface and Endpoints are all populated.
+	TransportRawUSB Transport = iota
+	// TransportHID means this entry came from Enumerate's hidapi walk, added
+	// by EnumerateAll because no raw USB interface was reported for it
+	// (typically because the OS's HID driver has already claimed the
+	// interface, which hides it from libusb). Bus, Address and Endpoints are
+	// left at their zero values; only Interface carries over from DeviceInfo.
+	TransportHID
+)
+
+// EnumerateAll merges EnumerateRaw and Enumerate into the single,
+// transport-tagged device list callers otherwise have to assemble by hand:
+// every raw USB interface EnumerateRaw finds, plus every hidapi device
+// Enumerate finds that isn't already covered by one of those interfaces.
+// RawDeviceInfo embeds DeviceInfo, so VendorID/ProductID/Path/... are
+// populated either way; Transport says which backend produced the entry and,
+// for TransportHID ones, that Bus/Address/Endpoints are unset.
+func EnumerateAll(vendorID, productID uint16) ([]RawDeviceInfo, error) {
+	raw, err := EnumerateRaw(vendorID, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	type vendorProductKey struct {
+		vendorID, productID uint16
+	}
+	type ifaceKey struct {
+		vendorProductKey
+		iface int
+	}
+	seenDevice := make(map[vendorProductKey]bool, len(raw))
+	seenIface := make(map[ifaceKey]bool, len(raw))
+	for _, info := range raw {
+		seenDevice[vendorProductKey{info.VendorID, info.ProductID}] = true
+		seenIface[ifaceKey{vendorProductKey{info.VendorID, info.ProductID}, info.Interface}] = true
+	}
+	for _, info := range Enumerate(vendorID, productID) {
+		vp := vendorProductKey{info.VendorID, info.ProductID}
+		// hidapi reports interface_number as -1 for a device it doesn't
+		// consider composite, even when EnumerateRaw found exactly one raw
+		// interface for the same vendor/product; match on vendor/product
+		// alone in that case instead of demanding an exact interface match.
+		if info.Interface < 0 {
+			if seenDevice[vp] {
+				continue
+			}
+		} else if seenIface[ifaceKey{vp, info.Interface}] {
+			continue
+		}
+		raw = append(raw, RawDeviceInfo{DeviceInfo: info, Transport: TransportHID})
+	}
+	return raw, nil
+}
+
+// EnumerateRaw returns every USB interface (not just ones the OS recognizes as
+// HID) matching the given vendor and product id, by walking the raw libusb
+// device list instead of hidapi's filtered one. As with Enumerate, a vendor or
+// product id of 0 matches anything.
+func EnumerateRaw(vendorID, productID uint16) ([]RawDeviceInfo, error) {
+	if err := rawInit(); err != nil {
+		return nil, err
+	}
+	var list **C.libusb_device
+	count := C.libusb_get_device_list(nil, &list)
+	if count < 0 {
+		return nil, fmt.Errorf("hid: failed to list USB devices: %v", count)
+	}
+	defer C.libusb_free_device_list(list, 1)
+
+	devices := (*[1 << 20]*C.libusb_device)(unsafe.Pointer(list))[:count:count]
+
+	var infos []RawDeviceInfo
+	for _, device := range devices {
+		var desc C.struct_libusb_device_descriptor
+		if C.libusb_get_device_descriptor(device, &desc) != 0 {
+			continue
+		}
+		if (vendorID != 0 && uint16(desc.idVendor) != vendorID) || (productID != 0 && uint16(desc.idProduct) != productID) {
+			continue
+		}
+		var config *C.struct_libusb_config_descriptor
+		if C.libusb_get_active_config_descriptor(device, &config) != 0 {
+			continue
+		}
+		ifaces := (*[1 << 10]C.struct_libusb_interface)(unsafe.Pointer(config._interface))[:config.bNumInterfaces:config.bNumInterfaces]
+		for _, iface := range ifaces {
+			settings := (*[1 << 10]C.struct_libusb_interface_descriptor)(unsafe.Pointer(iface.altsetting))[:iface.num_altsetting:iface.num_altsetting]
+			if len(settings) == 0 {
+				continue
+			}
+			setting := settings[0]
+
+			info := RawDeviceInfo{
+				DeviceInfo: DeviceInfo{
+					VendorID:  uint16(desc.idVendor),
+					ProductID: uint16(desc.idProduct),
+					Interface: int(setting.bInterfaceNumber),
+				},
+				Transport: TransportRawUSB,
+				Bus:       int(C.libusb_get_bus_number(device)),
+				Address:   int(C.libusb_get_device_address(device)),
+			}
+			endpoints := (*[1 << 10]C.struct_libusb_endpoint_descriptor)(unsafe.Pointer(setting.endpoint))[:setting.bNumEndpoints:setting.bNumEndpoints]
+			for _, ep := range endpoints {
+				info.Endpoints = append(info.Endpoints, EndpointInfo{
+					Address:       byte(ep.bEndpointAddress),
+					Interrupt:     C.libusb_transfer_type(ep.bmAttributes&0x3) == C.LIBUSB_TRANSFER_TYPE_INTERRUPT,
+					MaxPacketSize: int(ep.wMaxPacketSize),
+				})
+			}
+			infos = append(infos, info)
+		}
+		C.libusb_free_config_descriptor(config)
+	}
+	return infos, nil
+}
+
+// Open claims the interface described by this RawDeviceInfo and returns a
+// RawUSBDevice ready for bulk and interrupt transfers.
+//
+// It opens the exact bus+address libusb enumerated this interface from,
+// rather than the first device matching VendorID/ProductID: with two
+// identical devices plugged in (the whole reason EnumerateRaw records
+// Bus/Address), opening by VID/PID alone could silently hand back the wrong
+// physical device.
+func (info RawDeviceInfo) Open() (*RawUSBDevice, error) {
+	if err := rawInit(); err != nil {
+		return nil, err
+	}
+	device, err := findLibusbDevice(info.Bus, info.Address)
+	if err != nil {
+		return nil, err
+	}
+	var handle *C.libusb_device_handle
+	res := C.libusb_open(device, &handle)
+	// libusb_open takes its own reference to device (released by libusb_close),
+	// so our manual ref from findLibusbDevice is no longer needed either way.
+	C.libusb_unref_device(device)
+	if res < 0 {
+		return nil, fmt.Errorf("hid: failed to open raw USB device at bus %d address %d: %v", info.Bus, info.Address, res)
+	}
+	if res := C.libusb_claim_interface(handle, C.int(info.Interface)); res < 0 {
+		C.libusb_close(handle)
+		return nil, fmt.Errorf("hid: failed to claim interface %d: %v", info.Interface, res)
+	}
+	return &RawUSBDevice{
+		RawDeviceInfo: info,
+		handle:        handle,
+	}, nil
+}
+
+// findLibusbDevice re-walks the libusb device list to find the device at the
+// given bus/address, matching what EnumerateRaw recorded for this interface.
+func findLibusbDevice(bus, address int) (*C.libusb_device, error) {
+	var list **C.libusb_device
+	count := C.libusb_get_device_list(nil, &list)
+	if count < 0 {
+		return nil, fmt.Errorf("hid: failed to list USB devices: %v", count)
+	}
+	defer C.libusb_free_device_list(list, 1)
+
+	devices := (*[1 << 20]*C.libusb_device)(unsafe.Pointer(list))[:count:count]
+	for _, device := range devices {
+		if int(C.libusb_get_bus_number(device)) == bus && int(C.libusb_get_device_address(device)) == address {
+			// The reference taken here keeps device alive after the deferred
+			// libusb_free_device_list; Open drops it after libusb_open.
+			C.libusb_ref_device(device)
+			return device, nil
+		}
+	}
+	return nil, fmt.Errorf("hid: no USB device found at bus %d address %d", bus, address)
+}
+
+// RawUSBDevice is a live libusb connected device handle with a claimed
+// interface, bypassing the OS's HID abstraction entirely.
+type RawUSBDevice struct {
+	RawDeviceInfo
+	handle *C.libusb_device_handle
+
+	lock sync.Mutex
+}
+
+// Close releases the claimed interface and the underlying device handle.
+func (dev *RawUSBDevice) Close() error {
+	dev.lock.Lock()
+	defer dev.lock.Unlock()
+
+	if dev.handle != nil {
+		C.libusb_release_interface(dev.handle, C.int(dev.Interface))
+		C.libusb_close(dev.handle)
+		dev.handle = nil
+	}
+	return nil
+}
+
+const rawTransferTimeoutMillis = 5000
+
+// BulkWrite sends b as a single bulk transfer to the given OUT endpoint.
+func (dev *RawUSBDevice) BulkWrite(endpoint byte, b []byte) (int, error) {
+	return dev.transfer(endpoint, b, false)
+}
+
+// BulkRead reads a single bulk transfer from the given IN endpoint into b.
+func (dev *RawUSBDevice) BulkRead(endpoint byte, b []byte) (int, error) {
+	return dev.transfer(endpoint, b, false)
+}
+
+// InterruptWrite sends b as a single interrupt transfer to the given OUT endpoint.
+func (dev *RawUSBDevice) InterruptWrite(endpoint byte, b []byte) (int, error) {
+	return dev.transfer(endpoint, b, true)
+}
+
+// InterruptRead reads a single interrupt transfer from the given IN endpoint into b.
+func (dev *RawUSBDevice) InterruptRead(endpoint byte, b []byte) (int, error) {
+	return dev.transfer(endpoint, b, true)
+}
+
+func (dev *RawUSBDevice) transfer(endpoint byte, b []byte, interrupt bool) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	dev.lock.Lock()
+	handle := dev.handle
+	dev.lock.Unlock()
+
+	if handle == nil {
+		return 0, ErrDeviceClosed
+	}
+	var transferred C.int
+	var res C.int
+	if interrupt {
+		res = C.libusb_interrupt_transfer(handle, C.uchar(endpoint), (*C.uchar)(&b[0]), C.int(len(b)), &transferred, rawTransferTimeoutMillis)
+	} else {
+		res = C.libusb_bulk_transfer(handle, C.uchar(endpoint), (*C.uchar)(&b[0]), C.int(len(b)), &transferred, rawTransferTimeoutMillis)
+	}
+	if res < 0 {
+		return int(transferred), fmt.Errorf("hid: raw USB transfer failed: %v", res)
+	}
+	return int(transferred), nil
+}
@@ -0,0 +1,121 @@
+// hid - Gopher Interface Devices (USB HID)
+// Copyright (c) 2017 Péter Szilágyi. All rights reserved.
+//
+// This file is released under the 3-clause BSD license.
+
+package hid
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Report is a single, fully-framed input report delivered by (*Device).Reports.
+type Report struct {
+	ID   uint8
+	Data []byte
+	When time.Time
+}
+
+// reportsReadTimeout bounds each individual ReadContext call inside the
+// Reports goroutine, so it keeps noticing ctx.Done()/Close even while no
+// report is arriving.
+const reportsReadTimeout = 100 * time.Millisecond
+
+// Reports starts a single background goroutine that reads from the device,
+// splits incoming bytes into reports by their leading report id, and
+// delivers them on the returned channel. Report sizes are taken from the
+// device's parsed ReportDescriptor when available, falling back to
+// reportSizes if the descriptor can't be read (e.g. older hidapi).
+//
+// The goroutine exits on ctx.Done() or Close, and writes exactly one error
+// (nil on a clean shutdown) to the returned error channel before returning.
+func (dev *Device) Reports(ctx context.Context, reportSizes map[uint8]int) (<-chan Report, <-chan error) {
+	reports := make(chan Report)
+	errs := make(chan error, 1)
+
+	sizes := reportSizes
+	if desc, err := dev.ReportDescriptor(); err == nil {
+		sizes = reportSizesFromDescriptor(desc)
+	}
+
+	go func() {
+		defer close(reports)
+
+		buf := make([]byte, maxReportSize(sizes))
+		for {
+			select {
+			case <-ctx.Done():
+				errs <- nil
+				return
+			default:
+			}
+
+			readCtx, cancel := context.WithTimeout(ctx, reportsReadTimeout)
+			n, err := dev.ReadContext(readCtx, buf)
+			cancel()
+
+			if err != nil {
+				if errors.Is(err, context.DeadlineExceeded) {
+					continue // no report within this slice, keep waiting
+				}
+				if errors.Is(err, context.Canceled) || errors.Is(err, ErrDeviceClosed) {
+					errs <- nil
+					return
+				}
+				errs <- err
+				return
+			}
+			if n == 0 {
+				continue
+			}
+
+			id := buf[0]
+			size := n
+			if want, ok := sizes[id]; ok && want+1 <= n {
+				size = want + 1
+			}
+			data := make([]byte, size-1)
+			copy(data, buf[1:size])
+
+			select {
+			case reports <- Report{ID: id, Data: data, When: time.Now()}:
+			case <-ctx.Done():
+				errs <- nil
+				return
+			}
+		}
+	}()
+	return reports, errs
+}
+
+func reportSizesFromDescriptor(desc *ReportDescriptor) map[uint8]int {
+	sizes := make(map[uint8]int)
+	var walk func(c Collection)
+	walk = func(c Collection) {
+		for _, f := range c.Fields[InputReport] {
+			bits := f.BitOffset + f.BitSize
+			if bytes := (bits + 7) / 8; bytes > sizes[f.ReportID] {
+				sizes[f.ReportID] = bytes
+			}
+		}
+		for _, nested := range c.Collections {
+			walk(nested)
+		}
+	}
+	for _, c := range desc.Collections {
+		walk(c)
+	}
+	return sizes
+}
+
+func maxReportSize(sizes map[uint8]int) int {
+	max := 64 // hidapi's usual report buffer size, used when nothing better is known
+	for _, size := range sizes {
+		if size+1 > max {
+			max = size + 1
+		}
+	}
+	return max
+}
@@ -0,0 +1,76 @@
+// hid - Gopher Interface Devices (USB HID)
+// Copyright (c) 2017 Péter Szilágyi. All rights reserved.
+//
+// This file is released under the 3-clause BSD license.
+
+package hid
+
+import (
+	"context"
+)
+
+// EventType distinguishes the two kinds of notifications a Watcher delivers.
+type EventType int
+
+const (
+	// DeviceArrived is sent when a device matching the Watcher's Filter is
+	// plugged in (or already present when Watch is called).
+	DeviceArrived EventType = iota
+	// DeviceRemoved is sent when a previously reported device disappears.
+	DeviceRemoved
+)
+
+// Event is a single hotplug notification delivered by a Watcher.
+type Event struct {
+	Type EventType
+	Info DeviceInfo
+}
+
+// Filter narrows a Watcher to a subset of devices. A zero value in any field
+// matches anything, mirroring Enumerate's vendor/product semantics.
+type Filter struct {
+	VendorID  uint16
+	ProductID uint16
+	UsagePage uint16
+}
+
+func (f Filter) matches(info DeviceInfo) bool {
+	if f.VendorID != 0 && info.VendorID != f.VendorID {
+		return false
+	}
+	if f.ProductID != 0 && info.ProductID != f.ProductID {
+		return false
+	}
+	if f.UsagePage != 0 && info.UsagePage != f.UsagePage {
+		return false
+	}
+	return true
+}
+
+// watchBackend is implemented once per platform (hid_watch_linux.go,
+// hid_watch_darwin.go, hid_watch_windows.go) and once generically
+// (hid_watch_poll.go) for everything else. It pushes raw arrival/removal
+// events for devices matching filter onto events until ctx is cancelled.
+type watchBackend interface {
+	run(ctx context.Context, filter Filter, events chan<- Event)
+}
+
+// Watch subscribes to device arrival/removal notifications matching filter.
+// The returned channel is closed once ctx is cancelled; callers should keep
+// draining it until then to avoid leaking the watcher goroutine.
+//
+// On Linux this is backed by a udev/netlink socket, on macOS by
+// IOHIDManagerRegisterDeviceMatchingCallback/...RemovalCallback, and on
+// Windows by RegisterDeviceNotification plus WM_DEVICECHANGE. Platforms
+// without a native notification stream fall back to a debounced Enumerate
+// poll, so callers see the same channel-based API everywhere.
+func Watch(ctx context.Context, filter Filter) <-chan Event {
+	events := make(chan Event)
+	backend := newWatchBackend()
+
+	go func() {
+		defer close(events)
+		backend.run(ctx, filter, events)
+	}()
+	return events
+}
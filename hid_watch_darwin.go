@@ -0,0 +1,137 @@
+// hid - Gopher Interface Devices (USB HID)
+// Copyright (c) 2017 Péter Szilágyi. All rights reserved.
+//
+// This file is released under the 3-clause BSD license.
+
+// +build darwin,!ios,cgo
+
+package hid
+
+/*
+#cgo CFLAGS: -DOS_DARWIN
+#cgo LDFLAGS: -framework CoreFoundation -framework IOKit
+
+#include <IOKit/hid/IOHIDManager.h>
+
+extern void goHIDDeviceCallback(void *context, IOReturn result, void *sender, IOHIDDeviceRef device);
+
+static IOHIDManagerRef hid_watch_new_manager() {
+	IOHIDManagerRef manager = IOHIDManagerCreate(kCFAllocatorDefault, kIOHIDOptionsTypeNone);
+	IOHIDManagerSetDeviceMatching(manager, NULL);
+	IOHIDManagerRegisterDeviceMatchingCallback(manager, (IOHIDDeviceCallback)goHIDDeviceCallback, NULL);
+	IOHIDManagerRegisterDeviceRemovalCallback(manager, (IOHIDDeviceCallback)goHIDDeviceCallback, NULL);
+	IOHIDManagerScheduleWithRunLoop(manager, CFRunLoopGetCurrent(), kCFRunLoopDefaultMode);
+	IOHIDManagerOpen(manager, kIOHIDOptionsTypeNone);
+	return manager;
+}
+
+// hid_watch_pump_slice runs the current thread's run loop just long enough
+// to dispatch any pending IOHIDManager callbacks, then returns. Driving the
+// manager this way instead of CFRunLoopRun() means there's never a run loop
+// left blocked forever for ctx cancellation to have to interrupt: the Go
+// loop around this call simply stops calling it.
+static void hid_watch_pump_slice(CFTimeInterval seconds) {
+	CFRunLoopRunInMode(kCFRunLoopDefaultMode, seconds, false);
+}
+*/
+import "C"
+
+import (
+	"context"
+	"runtime"
+	"time"
+	"unsafe"
+)
+
+// watchPollInterval bounds how long a single hid_watch_pump_slice call runs
+// the CFRunLoop for. It plays the same role readPollInterval does in
+// hid_context.go: since there's no portable way to interrupt CFRunLoopRun
+// from another goroutine, ctx cancellation is instead bounded by never
+// running the loop longer than this at a stretch.
+const watchPollInterval = 250 * time.Millisecond
+
+// ioKitWakeups is where the exported goHIDDeviceCallback (invoked by IOKit
+// while hid_watch_pump_slice dispatches callbacks for both arrivals and
+// removals) signals that the device set changed. The real details are
+// picked up via a fresh Enumerate, which keeps the cgo boundary to a single,
+// trivially safe wake-up.
+var ioKitWakeups = make(chan struct{}, 16)
+
+//export goHIDDeviceCallback
+func goHIDDeviceCallback(context unsafe.Pointer, result C.IOReturn, sender unsafe.Pointer, device C.IOHIDDeviceRef) {
+	select {
+	case ioKitWakeups <- struct{}{}:
+	default:
+	}
+}
+
+func newWatchBackend() watchBackend {
+	return &ioKitBackend{}
+}
+
+// ioKitBackend delivers hotplug events using IOHIDManagerRegisterDeviceMatchingCallback
+// and ...RemovalCallback, pumped from a run loop scheduled on this goroutine.
+type ioKitBackend struct{}
+
+func (b *ioKitBackend) run(ctx context.Context, filter Filter, events chan<- Event) {
+	// IOHIDManagerScheduleWithRunLoop ties the manager to whichever OS thread
+	// is current when hid_watch_new_manager runs; every later
+	// hid_watch_pump_slice call must land on that same thread or IOKit will
+	// never dispatch to it.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	manager := C.hid_watch_new_manager()
+	defer C.IOHIDManagerClose(manager, C.kIOHIDOptionsTypeNone)
+
+	known := make(map[string]DeviceInfo)
+	emit := func() bool {
+		seen := make(map[string]bool)
+		for _, info := range Enumerate(filter.VendorID, filter.ProductID) {
+			if !filter.matches(info) {
+				continue
+			}
+			seen[info.Path] = true
+			if _, ok := known[info.Path]; !ok {
+				known[info.Path] = info
+				select {
+				case events <- Event{Type: DeviceArrived, Info: info}:
+				case <-ctx.Done():
+					return false
+				}
+			}
+		}
+		for path, info := range known {
+			if !seen[path] {
+				delete(known, path)
+				select {
+				case events <- Event{Type: DeviceRemoved, Info: info}:
+				case <-ctx.Done():
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	if !emit() {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		C.hid_watch_pump_slice(C.CFTimeInterval(watchPollInterval.Seconds()))
+
+		select {
+		case <-ioKitWakeups:
+			if !emit() {
+				return
+			}
+		default:
+		}
+	}
+}
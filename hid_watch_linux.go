@@ -0,0 +1,164 @@
+// hid - Gopher Interface Devices (USB HID)
+// Copyright (c) 2017 Péter Szilágyi. All rights reserved.
+//
+// This file is released under the 3-clause BSD license. Note however that this
+// backend depends on libudev, released under LGPL 2.1 or later.
+
+// +build linux,cgo
+
+package hid
+
+/*
+#cgo LDFLAGS: -ludev
+
+#include <libudev.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"context"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+func newWatchBackend() watchBackend {
+	return &udevBackend{}
+}
+
+// udevBackend delivers hotplug events by subscribing to the udev "hidraw"
+// (and "usb") subsystems over a netlink monitor socket, instead of polling.
+type udevBackend struct{}
+
+func (b *udevBackend) run(ctx context.Context, filter Filter, events chan<- Event) {
+	udev := C.udev_new()
+	if udev == nil {
+		// No udev on this system (e.g. a minimal container) - degrade to polling.
+		(&pollBackend{}).run(ctx, filter, events)
+		return
+	}
+	defer C.udev_unref(udev)
+
+	monitor := C.udev_monitor_new_from_netlink(udev, C.CString("udev"))
+	if monitor == nil {
+		(&pollBackend{}).run(ctx, filter, events)
+		return
+	}
+	defer C.udev_monitor_unref(monitor)
+
+	C.udev_monitor_filter_add_match_subsystem_devtype(monitor, C.CString("hidraw"), nil)
+	C.udev_monitor_filter_add_match_subsystem_devtype(monitor, C.CString("usb"), C.CString("usb_device"))
+	C.udev_monitor_enable_receiving(monitor)
+	fd := int(C.udev_monitor_get_fd(monitor))
+
+	// known caches each arrived device's DeviceInfo by path, the same way
+	// hid_watch_darwin.go/hid_watch_windows.go do: re-enumerating on a
+	// "remove" action is too late, the device node is already gone, so
+	// removal events must carry whatever arrival last recorded for that
+	// path. Diffing a fresh Enumerate against known on every wakeup also
+	// dedups arrivals for free, since a single physical device plugging in
+	// can fire both a "usb" and a "hidraw" udev event.
+	known := make(map[string]DeviceInfo)
+	emit := func() bool {
+		seen := make(map[string]bool)
+		for _, info := range Enumerate(filter.VendorID, filter.ProductID) {
+			if !filter.matches(info) {
+				continue
+			}
+			seen[info.Path] = true
+			if _, ok := known[info.Path]; !ok {
+				known[info.Path] = info
+				select {
+				case events <- Event{Type: DeviceArrived, Info: info}:
+				case <-ctx.Done():
+					return false
+				}
+			}
+		}
+		for path, info := range known {
+			if !seen[path] {
+				delete(known, path)
+				select {
+				case events <- Event{Type: DeviceRemoved, Info: info}:
+				case <-ctx.Done():
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	// Report whatever already matches before the first netlink event arrives.
+	if !emit() {
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		close(done)
+	}()
+
+	for {
+		if !waitReadable(fd, done) {
+			return
+		}
+		device := C.udev_monitor_receive_device(monitor)
+		if device == nil {
+			continue
+		}
+		action := C.GoString(C.udev_device_get_action(device))
+		vendor, product := udevIDs(device)
+		C.udev_device_unref(device)
+
+		// Only skip events whose ids were readable and differ: hidraw nodes
+		// carry no idVendor/idProduct attributes and a removed device's sysfs
+		// entry is already gone, so those read as 0 and are left to emit.
+		if (filter.VendorID != 0 && vendor != 0 && vendor != filter.VendorID) || (filter.ProductID != 0 && product != 0 && product != filter.ProductID) {
+			continue
+		}
+		if action != "add" && action != "remove" {
+			continue
+		}
+		if !emit() {
+			return
+		}
+	}
+}
+
+// waitReadable blocks until fd has data to read or done is closed, returning
+// false in the latter case so the caller can unwind without reading.
+func waitReadable(fd int, done <-chan struct{}) bool {
+	for {
+		select {
+		case <-done:
+			return false
+		default:
+		}
+		var fds syscall.FdSet
+		fds.Bits[fd/64] |= 1 << (uint(fd) % 64)
+		timeout := syscall.Timeval{Sec: 0, Usec: int64(250 * time.Millisecond / time.Microsecond)}
+		n, err := syscall.Select(fd+1, &fds, nil, nil, &timeout)
+		if err != nil {
+			continue
+		}
+		if n > 0 {
+			return true
+		}
+	}
+}
+
+func udevIDs(device *C.struct_udev_device) (vendor, product uint16) {
+	if raw := C.udev_device_get_sysattr_value(device, C.CString("idVendor")); raw != nil {
+		if v, err := strconv.ParseUint(C.GoString(raw), 16, 16); err == nil {
+			vendor = uint16(v)
+		}
+	}
+	if raw := C.udev_device_get_sysattr_value(device, C.CString("idProduct")); raw != nil {
+		if v, err := strconv.ParseUint(C.GoString(raw), 16, 16); err == nil {
+			product = uint16(v)
+		}
+	}
+	return vendor, product
+}
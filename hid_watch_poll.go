@@ -0,0 +1,73 @@
+// hid - Gopher Interface Devices (USB HID)
+// Copyright (c) 2017 Péter Szilágyi. All rights reserved.
+//
+// This file is released under the 3-clause BSD license.
+
+// +build !linux !cgo
+// +build !darwin !cgo
+// +build !windows !cgo
+
+package hid
+
+import (
+	"context"
+	"time"
+)
+
+// pollInterval is how often the fallback watcher re-runs Enumerate. It is
+// intentionally close to what downstream pollers (e.g. the Ledger hub in
+// go-ethereum) already used, so switching to Watch costs them nothing.
+const pollInterval = 500 * time.Millisecond
+
+func newWatchBackend() watchBackend {
+	return &pollBackend{}
+}
+
+// pollBackend is the fallback used on platforms without a native hotplug
+// notification API: it debounces a plain Enumerate loop into arrival/removal
+// events so callers get the same channel-based interface everywhere.
+type pollBackend struct{}
+
+func (b *pollBackend) run(ctx context.Context, filter Filter, events chan<- Event) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	known := make(map[string]DeviceInfo)
+	poll := func() {
+		seen := make(map[string]bool)
+		for _, info := range Enumerate(filter.VendorID, filter.ProductID) {
+			if !filter.matches(info) {
+				continue
+			}
+			seen[info.Path] = true
+			if _, ok := known[info.Path]; !ok {
+				known[info.Path] = info
+				select {
+				case events <- Event{Type: DeviceArrived, Info: info}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+		for path, info := range known {
+			if !seen[path] {
+				delete(known, path)
+				select {
+				case events <- Event{Type: DeviceRemoved, Info: info}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+
+	poll()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			poll()
+		}
+	}
+}
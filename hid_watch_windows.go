@@ -0,0 +1,158 @@
+// hid - Gopher Interface Devices (USB HID)
+// Copyright (c) 2017 Péter Szilágyi. All rights reserved.
+//
+// This file is released under the 3-clause BSD license.
+
+// +build windows,cgo
+
+package hid
+
+/*
+#cgo LDFLAGS: -luser32
+
+#define WIN32_LEAN_AND_MEAN
+#include <windows.h>
+#include <dbt.h>
+
+extern void goDeviceChange();
+
+static LRESULT CALLBACK hidWatchWndProc(HWND hwnd, UINT msg, WPARAM wParam, LPARAM lParam) {
+	switch (msg) {
+	case WM_DEVICECHANGE:
+		if (wParam == DBT_DEVICEARRIVAL || wParam == DBT_DEVICEREMOVECOMPLETE) {
+			goDeviceChange();
+		}
+		return TRUE;
+	case WM_CLOSE:
+		// Runs on the same thread as the window, unlike the Go-side stop
+		// request, so it's safe to tear the window down here.
+		DestroyWindow(hwnd);
+		return 0;
+	case WM_DESTROY:
+		UnregisterDeviceNotification((HDEVNOTIFY)GetWindowLongPtr(hwnd, GWLP_USERDATA));
+		PostQuitMessage(0);
+		return 0;
+	}
+	return DefWindowProc(hwnd, msg, wParam, lParam);
+}
+
+static HWND hid_watch_register() {
+	WNDCLASS wc;
+	ZeroMemory(&wc, sizeof(wc));
+	wc.lpfnWndProc = hidWatchWndProc;
+	wc.lpszClassName = TEXT("GoHidWatchWindow");
+	RegisterClass(&wc);
+
+	HWND hwnd = CreateWindowEx(0, TEXT("GoHidWatchWindow"), TEXT("GoHidWatch"), 0, 0, 0, 0, 0, HWND_MESSAGE, NULL, NULL, NULL);
+
+	DEV_BROADCAST_DEVICEINTERFACE filter;
+	ZeroMemory(&filter, sizeof(filter));
+	filter.dbcc_size = sizeof(filter);
+	filter.dbcc_devicetype = DBT_DEVTYP_DEVICEINTERFACE;
+	HDEVNOTIFY notify = RegisterDeviceNotification(hwnd, &filter, DEVICE_NOTIFY_WINDOW_HANDLE);
+	SetWindowLongPtr(hwnd, GWLP_USERDATA, (LONG_PTR)notify);
+
+	return hwnd;
+}
+
+// hid_watch_request_stop asks the message-pump thread to tear its hidden
+// window down. PostMessage is safe to call across threads, unlike
+// DestroyWindow itself, which must run on the window's own thread.
+static void hid_watch_request_stop(HWND hwnd) {
+	PostMessage(hwnd, WM_CLOSE, 0, 0);
+}
+*/
+import "C"
+
+import (
+	"context"
+	"runtime"
+)
+
+// win32Wakeups is signalled from the exported goDeviceChange, invoked on the
+// hidden window's message-pump thread whenever Windows posts WM_DEVICECHANGE
+// with DBT_DEVICEARRIVAL or DBT_DEVICEREMOVECOMPLETE.
+var win32Wakeups = make(chan struct{}, 16)
+
+//export goDeviceChange
+func goDeviceChange() {
+	select {
+	case win32Wakeups <- struct{}{}:
+	default:
+	}
+}
+
+func newWatchBackend() watchBackend {
+	return &win32Backend{}
+}
+
+// win32Backend delivers hotplug events via RegisterDeviceNotification and a
+// WM_DEVICECHANGE message pump running on a dedicated, hidden window.
+type win32Backend struct{}
+
+func (b *win32Backend) run(ctx context.Context, filter Filter, events chan<- Event) {
+	hwnds := make(chan C.HWND, 1)
+	pumpDone := make(chan struct{})
+	go func() {
+		defer close(pumpDone)
+		// The window belongs to the thread that creates it, and only that
+		// thread's GetMessage receives its messages.
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		hwnd := C.hid_watch_register()
+		hwnds <- hwnd
+		var msg C.MSG
+		for C.GetMessage(&msg, hwnd, 0, 0) > 0 {
+			C.TranslateMessage(&msg)
+			C.DispatchMessage(&msg)
+		}
+	}()
+	defer func() {
+		C.hid_watch_request_stop(<-hwnds)
+		<-pumpDone // wait for the window/notification handle to actually be torn down
+	}()
+
+	known := make(map[string]DeviceInfo)
+	emit := func() bool {
+		seen := make(map[string]bool)
+		for _, info := range Enumerate(filter.VendorID, filter.ProductID) {
+			if !filter.matches(info) {
+				continue
+			}
+			seen[info.Path] = true
+			if _, ok := known[info.Path]; !ok {
+				known[info.Path] = info
+				select {
+				case events <- Event{Type: DeviceArrived, Info: info}:
+				case <-ctx.Done():
+					return false
+				}
+			}
+		}
+		for path, info := range known {
+			if !seen[path] {
+				delete(known, path)
+				select {
+				case events <- Event{Type: DeviceRemoved, Info: info}:
+				case <-ctx.Done():
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	if !emit() {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-win32Wakeups:
+			if !emit() {
+				return
+			}
+		}
+	}
+}